@@ -14,24 +14,43 @@
 	among other sources. It uses format slices of strings as compact descriptions of the layout
 	of the Go structs.
 
-	Format characters (some characters like H have been reserved for future implementation of unsigned numbers):
+	Format characters:
 		? - bool, packed size 1 byte
-		h, H - int, packed size 2 bytes (in future it will support pack/unpack of int8, uint8 values)
-		i, I, l, L - int, packed size 4 bytes (in future it will support pack/unpack of int16, uint16, int32, uint32 values)
-		q, Q - int, packed size 8 bytes (in future it will support pack/unpack of int64, uint64 values)
+		B - uint8, packed size 1 byte
+		c - byte, packed size 1 byte
+		h, H - int16, uint16, packed size 2 bytes
+		i, I, l, L - int32, uint32, packed size 4 bytes
+		q, Q - int64, uint64, packed size 8 bytes
 		f - float32, packed size 4 bytes
 		d - float64, packed size 8 bytes
+		v - uint64, unsigned LEB128 varint, packed size 1-10 bytes depending on magnitude
+		z - int64, zigzag-encoded varint, packed size 1-10 bytes depending on magnitude
 		Ns - string, packed size N bytes, N is a number of runes to pack/unpack
+		Np - Pascal-style length-prefixed string, packed size N bytes (1 length byte + N-1 data bytes)
+		Nx - N pad bytes; doesn't consume or produce a value
+
+	A leading count before any other letter is a repeat count rather than a byte
+	count: "4h" packs/unpacks a []int16 of length 4. A token may be prefixed with
+	"<" or ">" to select little/big endian, or "@"/"=" to select
+	binary.NativeEndian; a token with no prefix inherits whichever byte order
+	the previous token selected, defaulting to big endian.
+
+	Pack/UnPack build (or consume) a []byte in memory. AppendPack, PackTo and
+	UnPackFrom cover the append-into-caller-buffer and streaming cases; see
+	stream.go. The token grammar itself - byte order, repeat count and the
+	scalar letters - lives in format.go. Pack/UnPack parse format on every
+	call; Compile parses it once into a *Format that Pack/UnPack can be
+	replayed against repeatedly without re-parsing, see compiled.go.
+
+	Formats containing "v"/"z" don't have a single static size, so CalcSize
+	rejects them; use CalcMinSize/CalcMaxSize to bound the packed size instead.
 
  */
 package binary_pack
 
 import (
-	"strings"
-	"strconv"
-	"errors"
 	"encoding/binary"
-	"bytes"
+	"errors"
 	"fmt"
 )
 
@@ -40,269 +59,88 @@ type BinaryPack struct {}
 // Return a byte slice containing the values of msg slice packed according to the given format.
 // The items of msg slice must match the values required by the format exactly.
 func (bp *BinaryPack) Pack(format []string, msg []interface{}) ([]byte, error) {
-	if len(format) > len(msg) {
-		return nil, errors.New(fmt.Sprintf("Format (%d) is longer than values (%d) to pack", len(format), len(msg)))
-	}
-
-	var endianess binary.ByteOrder = binary.BigEndian // defaults to big endian
-
-	res := []byte{}
-
-	for i, f := range format {
-		if f[0] == '<' {
-			// little endian
-			endianess = binary.LittleEndian
-			f = f[1:]
-		} else if f[0] == '>' {
-			endianess = binary.BigEndian
-			f = f[1:]
-		}
-		switch f {
-		case "?":
-			casted_value, ok := msg[i].(bool)
-			if !ok {
-				return nil, errors.New("Type of passed value doesn't match to expected '" + f + "' (bool)")
-			}
-			res = append(res, boolToBytes(endianess, casted_value)...)
-		case "B":
-			casted_value, ok := msg[i].(uint8)
-			if !ok {
-				return nil, errors.New("Type of passed value doesn't match to expected '" + f + "' (int, 1 bytes)")
-			}
-			res = append(res, uint8ToBytes(endianess, casted_value, 1)...)
-		case "h", "H":
-			casted_value, ok := msg[i].(uint16)
-			if !ok {
-				return nil, errors.New("Type of passed value doesn't match to expected '" + f + "' (int, 2 bytes)")
-			}
-			res = append(res, uint16ToBytes(endianess, casted_value, 2)...)
-		case "i", "I", "l", "L":
-			casted_value, ok := msg[i].(uint32)
-			if !ok {
-				return nil, errors.New("Type of passed value " + string(msg[i].(uint32)) + " doesn't match to expected '" + f + "' (int, 4 bytes)")
-			}
-			res = append(res, uint32ToBytes(endianess, casted_value, 4)...)
-		case "q", "Q":
-			casted_value, ok := msg[i].(uint64)
-			if !ok {
-				return nil, errors.New("Type of passed value doesn't match to expected '" + f + "' (int, 8 bytes)")
-			}
-			res = append(res, uint64ToBytes(endianess, casted_value, 8)...)
-		case "f":
-			casted_value, ok := msg[i].(float32)
-			if !ok {
-				return nil, errors.New("Type of passed value doesn't match to expected '" + f + "' (float32)")
-			}
-			res = append(res, float32ToBytes(endianess, casted_value, 4)...)
-		case "d":
-			casted_value, ok := msg[i].(float64)
-			if !ok {
-				return nil, errors.New("Type of passed value doesn't match to expected '" + f + "' (float64)")
-			}
-			res = append(res, float64ToBytes(endianess, casted_value, 8)...)
-		default:
-			if strings.Contains(f, "s") {
-				casted_value, ok := msg[i].(string)
-				if !ok {
-					return nil, errors.New("Type of passed value doesn't match to expected '" + f + "' (string)")
-				}
-				n, _ := strconv.Atoi(strings.TrimRight(f, "s"))
-				res = append(res, []byte(fmt.Sprintf("%s%s",
-					casted_value, strings.Repeat("\x00", n-len(casted_value))))...)
-			} else {
-				return nil, errors.New("Unexpected format token: '" + f + "'")
-			}
-		}
-	}
-
-	return res, nil
+	return bp.AppendPack(nil, format, msg)
 }
 
 // Unpack the byte slice (presumably packed by Pack(format, msg)) according to the given format.
 // The result is a []interface{} slice even if it contains exactly one item.
-// The byte slice must contain not less the amount of data required by the format
-// (len(msg) must more or equal CalcSize(format)).
+// The byte slice must contain not less the amount of data required by the format;
+// for formats without "v"/"z" tokens that's CalcSize(format).
 func (bp *BinaryPack) UnPack(format []string, msg []byte) ([]interface{}, error) {
-	expected_size, err := bp.CalcSize(format)
-
+	ops, err := compileFormat(format)
 	if err != nil {
 		return nil, err
 	}
-
-	if expected_size > len(msg) {
-		return nil, errors.New("Expected size is bigger than actual size of message")
-	}
-
-	res := []interface{}{}
-
-	var endianess binary.ByteOrder = binary.BigEndian // default big endian
-
-	for _, f := range format {
-		if f[0] == '<' {
-			// little endian
-			endianess = binary.LittleEndian
-			f = f[1:]
-		} else if f[0] == '>' {
-			endianess = binary.BigEndian
-			f = f[1:]
-		}
-		switch f {
-		case "?":
-			res = append(res, bytesToBool(endianess, msg[:1]))
-			msg = msg[1:]
-		case "B":
-			res = append(res, bytesToInt8(endianess, msg[:1]))
-			msg = msg[1:]
-		case "h", "H":
-			res = append(res, bytesToInt16(endianess, msg[:2]))
-			msg = msg[2:]
-		case "i", "I", "l", "L":
-			res = append(res, bytesToInt32(endianess, msg[:4]))
-			msg = msg[4:]
-		case "q", "Q":
-			res = append(res, bytesToInt64(endianess, msg[:8]))
-			msg = msg[8:]
-		case "f":
-			res = append(res, bytesToFloat32(endianess, msg[:4]))
-			msg = msg[4:]
-		case "d":
-			res = append(res, bytesToFloat64(endianess, msg[:8]))
-			msg = msg[8:]
-		default:
-			if strings.Contains(f, "s") {
-				n, _ := strconv.Atoi(strings.TrimRight(f, "s"))
-				res = append(res, string(msg[:n]))
-				msg = msg[n:]
-			} else {
-				return nil, errors.New("Unexpected format token: '" + f + "'")
-			}
-		}
-	}
-
-	return res, nil
+	return unpackOps(ops, msg)
 }
 
 // Return the size of the struct (and hence of the byte slice) corresponding to the given format.
+// Formats containing the variable-length "v"/"z" tokens have no single static
+// size; CalcSize returns an error for those, use CalcMinSize/CalcMaxSize instead.
 func (bp *BinaryPack) CalcSize(format []string) (int, error) {
-	var size int
-
-	for _, f := range format {
-		// skip endianess switches
-		if f[0] == '<' || f[0] == '>' {
-			f = f[1:]
-		}
-		switch f {
-		case "?":
-			size = size + 1
-		case "B":
-			size = size + 1
-		case "h", "H":
-			size = size + 2
-		case "i", "I", "l", "L", "f":
-			size = size + 4
-		case "q", "Q", "d":
-			size = size + 8
-		default:
-			if strings.Contains(f, "s") {
-				n, _ := strconv.Atoi(strings.TrimRight(f, "s"))
-				size = size + n
-			} else {
-				return 0, errors.New("Unexpected format token: '" + f + "'")
-			}
-		}
-	}
-
-	return size, nil
-}
-
-func boolToBytes(endianess binary.ByteOrder, x bool) []byte {
-	if x {
-		return uint32ToBytes(endianess, 1, 1)
-	}
-	return uint32ToBytes(endianess, 0, 1)
-}
-
-func bytesToBool(endianess binary.ByteOrder, b []byte) bool {
-	return bytesToInt8(endianess, b) > 0
+	return calcSize(format, 0)
 }
 
-func uint32ToBytes(endianess binary.ByteOrder, n uint32, size int) []byte {
-	buf := bytes.NewBuffer([]byte{})
-	binary.Write(buf, endianess, uint32(n))
-	return buf.Bytes()[0:size]
+// CalcMinSize returns the smallest number of bytes the byte slice for the
+// given format could be, treating each "v"/"z" token as contributing its
+// minimum possible encoding of 1 byte.
+func (bp *BinaryPack) CalcMinSize(format []string) (int, error) {
+	return calcSize(format, 1)
 }
 
-func uint64ToBytes(endianess binary.ByteOrder, n uint64, size int) []byte {
-	buf := bytes.NewBuffer([]byte{})
-	binary.Write(buf, endianess, uint64(n))
-	return buf.Bytes()[0:size]
+// CalcMaxSize returns the largest number of bytes the byte slice for the
+// given format could be, treating each "v"/"z" token as contributing its
+// maximum possible encoding of binary.MaxVarintLen64 bytes.
+func (bp *BinaryPack) CalcMaxSize(format []string) (int, error) {
+	return calcSize(format, binary.MaxVarintLen64)
 }
 
-func uint16ToBytes(endianess binary.ByteOrder, n uint16, size int) []byte {
-	buf := bytes.NewBuffer([]byte{})
-	binary.Write(buf, endianess, uint16(n))
-	return buf.Bytes()[0:size]
-}
-
-func uint8ToBytes(endianess binary.ByteOrder, n uint8, size int) []byte {
-	buf := bytes.NewBuffer([]byte{})
-	binary.Write(buf, endianess, uint8(n))
-	return buf.Bytes()[0:size]
-}
-
-func bytesToInt8(endianess binary.ByteOrder, b []byte) uint8 {
-	buf := bytes.NewBuffer(b)
-
-	var x uint8
-	binary.Read(buf, endianess, &x)
-	return x
-}
-
-func bytesToInt16(endianess binary.ByteOrder, b []byte) uint16 {
-
-	buf := bytes.NewBuffer(b)
-
-	var x uint16
-	binary.Read(buf, endianess, &x)
-	return x
-}
-func bytesToInt32(endianess binary.ByteOrder, b []byte) uint32 {
-	buf := bytes.NewBuffer(b)
-
-	var x uint32
-	binary.Read(buf, endianess, &x)
-	return x
-}
-func bytesToInt64(endianess binary.ByteOrder, b []byte) uint64 {
-	buf := bytes.NewBuffer(b)
-
-	var x uint64
-	binary.Read(buf, endianess, &x)
-	return x
-}
-
-func float32ToBytes(endianess binary.ByteOrder, n float32, size int) []byte {
-	buf := bytes.NewBuffer([]byte{})
-	binary.Write(buf, endianess, n)
-	return buf.Bytes()[0:size]
-}
-
-func bytesToFloat32(endianess binary.ByteOrder, b []byte) float32 {
-	var x float32
-	buf := bytes.NewBuffer(b)
-	binary.Read(buf, endianess, &x)
-	return x
+// calcSize sums the packed size of format. varintSize is the size attributed
+// to each "v"/"z" token; 0 means such a token is an error (CalcSize), any
+// other value bounds it (CalcMinSize/CalcMaxSize).
+func calcSize(format []string, varintSize int) (int, error) {
+	ops, err := compileFormat(format)
+	if err != nil {
+		return 0, err
+	}
+	return opsSize(ops, varintSize)
 }
 
-func float64ToBytes(endianess binary.ByteOrder, n float64, size int) []byte {
-	buf := bytes.NewBuffer([]byte{})
-	binary.Write(buf, endianess, n)
-	return buf.Bytes()[0:size]
+// unpackPascalString decodes a Pascal-style string from the first n bytes of
+// msg: a single length byte followed by up to n-1 data bytes.
+func unpackPascalString(msg []byte, n int) (string, int, error) {
+	if n < 1 {
+		return "", 0, errors.New("Pascal string format token 'p' requires a count of at least 1")
+	}
+	if len(msg) < n {
+		return "", 0, errShortMessage
+	}
+	length := int(msg[0])
+	if length > n-1 {
+		length = n - 1
+	}
+	return string(msg[1 : 1+length]), n, nil
 }
 
-func bytesToFloat64(endianess binary.ByteOrder, b []byte) float64 {
-	var x float64
-	buf := bytes.NewBuffer(b)
-	binary.Read(buf, endianess, &x)
-	return x
+// packPascalString appends s to dst as a Pascal-style string occupying
+// exactly n bytes: a length byte followed by len(s) data bytes, then zero
+// padding up to n bytes total. It errors, rather than truncating, if s
+// doesn't fit in n-1 data bytes or if it's too long for the single length
+// byte to represent.
+func packPascalString(dst []byte, n int, s string) ([]byte, error) {
+	if n < 1 {
+		return nil, errors.New("Pascal string format token 'p' requires a count of at least 1")
+	}
+	if len(s) > n-1 {
+		return nil, fmt.Errorf("string %q longer than declared size %d (max %d data bytes)", s, n, n-1)
+	}
+	if len(s) > 255 {
+		return nil, fmt.Errorf("string %q too long for a Pascal string's 1-byte length prefix", s)
+	}
+	dst = append(dst, byte(len(s)))
+	dst = append(dst, s...)
+	for pad := n - 1 - len(s); pad > 0; pad-- {
+		dst = append(dst, 0)
+	}
+	return dst, nil
 }
@@ -0,0 +1,61 @@
+package binary_pack
+
+import "fmt"
+
+/*
+	Format is a pre-parsed version of the []string format slices Pack/UnPack
+	take. Compile parses a format once into a []formatOp program and a
+	precomputed total size; Format.Pack/Format.UnPack then replay that
+	program without re-splitting the byte-order prefix, re-running
+	strconv.Atoi on the leading count or re-deriving the scalar letter's
+	size on every call. Use it when the same format packs/unpacks many
+	records in a hot loop; for one-off use, Pack/UnPack are simpler.
+*/
+
+type Format struct {
+	ops  []formatOp
+	size int // -1 if the format contains "v"/"z" tokens and has no static size
+}
+
+// Compile parses format into a *Format that can be packed/unpacked
+// repeatedly without re-parsing the format string.
+func (bp *BinaryPack) Compile(format []string) (*Format, error) {
+	ops, err := compileFormat(format)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := opsSize(ops, 0)
+	if err != nil {
+		size = -1
+	}
+
+	return &Format{ops: ops, size: size}, nil
+}
+
+// Pack returns a byte slice containing the values of msg packed according to
+// f, equivalent to BinaryPack.Pack(format, msg) for the format f was
+// compiled from, but without re-parsing it.
+func (f *Format) Pack(msg []interface{}) ([]byte, error) {
+	var dst []byte
+	if f.size >= 0 {
+		dst = make([]byte, 0, f.size)
+	}
+	return appendPackOps(dst, f.ops, msg)
+}
+
+// UnPack decodes data according to f, equivalent to BinaryPack.UnPack(format,
+// data) for the format f was compiled from, but without re-parsing it.
+func (f *Format) UnPack(data []byte) ([]interface{}, error) {
+	return unpackOps(f.ops, data)
+}
+
+// Size returns the packed size in bytes of f. It returns an error if f was
+// compiled from a format containing "v"/"z" tokens, which have no single
+// static size.
+func (f *Format) Size() (int, error) {
+	if f.size < 0 {
+		return 0, fmt.Errorf("Format contains variable-length tokens and has no static size")
+	}
+	return f.size, nil
+}
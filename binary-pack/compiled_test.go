@@ -0,0 +1,106 @@
+package binary_pack
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatPackUnPackRoundTrip(t *testing.T) {
+	bp := &BinaryPack{}
+	format := []string{"<i", "H", "4B"}
+	msg := []interface{}{int32(-7), uint16(42), []uint8{1, 2, 3, 4}}
+
+	f, err := bp.Compile(format)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	size, err := f.Size()
+	if err != nil {
+		t.Fatalf("Size: %v", err)
+	}
+
+	data, err := f.Pack(msg)
+	if err != nil {
+		t.Fatalf("Format.Pack: %v", err)
+	}
+	if len(data) != size {
+		t.Fatalf("Format.Pack produced %d bytes, Size reported %d", len(data), size)
+	}
+	if cap(data) != size {
+		t.Fatalf("Format.Pack should preallocate exactly Size() bytes, got cap %d for size %d", cap(data), size)
+	}
+
+	out, err := f.UnPack(data)
+	if err != nil {
+		t.Fatalf("Format.UnPack: %v", err)
+	}
+	if !reflect.DeepEqual(out, msg) {
+		t.Fatalf("Format round trip mismatch: got %v, want %v", out, msg)
+	}
+}
+
+func TestFormatSizeRejectsVarintTokens(t *testing.T) {
+	bp := &BinaryPack{}
+	f, err := bp.Compile([]string{"v"})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if _, err := f.Size(); err == nil {
+		t.Fatal("expected Size to reject a compiled 'v' token, got nil error")
+	}
+}
+
+func BenchmarkPack(b *testing.B) {
+	bp := &BinaryPack{}
+	format := []string{"<i", "H", "4B"}
+	msg := []interface{}{int32(-7), uint16(42), []uint8{1, 2, 3, 4}}
+	f, err := bp.Compile(format)
+	if err != nil {
+		b.Fatalf("Compile: %v", err)
+	}
+
+	b.Run("Uncompiled", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := bp.Pack(format, msg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Compiled", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := f.Pack(msg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkUnPack(b *testing.B) {
+	bp := &BinaryPack{}
+	format := []string{"<i", "H", "4B"}
+	msg := []interface{}{int32(-7), uint16(42), []uint8{1, 2, 3, 4}}
+	f, err := bp.Compile(format)
+	if err != nil {
+		b.Fatalf("Compile: %v", err)
+	}
+	data, err := f.Pack(msg)
+	if err != nil {
+		b.Fatalf("Pack: %v", err)
+	}
+
+	b.Run("Uncompiled", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := bp.UnPack(format, data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Compiled", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := f.UnPack(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
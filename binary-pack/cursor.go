@@ -0,0 +1,253 @@
+package binary_pack
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+/*
+	Packer and Unpacker build up (or read down) a byte slice field by field
+	using a cursor, for layouts a single up-front format string can't
+	express because later fields depend on earlier ones - e.g. a length
+	field followed by that many bytes, or a discriminator byte that selects
+	which fields follow. Every Pack and Unpack method uses big-endian byte
+	order, matching Pack/UnPack's default. The first error encountered is
+	latched: once set, further calls are no-ops that keep returning it, so
+	a long call sequence only needs one error check at the end instead of
+	one after every field.
+*/
+
+// Packer appends typed values to a growing []byte buffer.
+type Packer struct {
+	buf []byte
+	err error
+}
+
+// NewPacker returns an empty Packer.
+func NewPacker() *Packer {
+	return &Packer{}
+}
+
+// Bytes returns the buffer packed so far.
+func (p *Packer) Bytes() []byte {
+	return p.buf
+}
+
+// Err returns the first error encountered by any Pack* call, or nil if none
+// occurred.
+func (p *Packer) Err() error {
+	return p.err
+}
+
+func (p *Packer) packScalar(letter byte, value interface{}) *Packer {
+	if p.err != nil {
+		return p
+	}
+	var err error
+	p.buf, err = packScalar(p.buf, binary.BigEndian, letter, value)
+	p.err = err
+	return p
+}
+
+func (p *Packer) PackBool(v bool) *Packer       { return p.packScalar('?', v) }
+func (p *Packer) PackByte(v byte) *Packer       { return p.packScalar('B', v) }
+func (p *Packer) PackInt16(v int16) *Packer     { return p.packScalar('h', v) }
+func (p *Packer) PackUint16(v uint16) *Packer   { return p.packScalar('H', v) }
+func (p *Packer) PackInt32(v int32) *Packer     { return p.packScalar('i', v) }
+func (p *Packer) PackUint32(v uint32) *Packer   { return p.packScalar('I', v) }
+func (p *Packer) PackInt64(v int64) *Packer     { return p.packScalar('q', v) }
+func (p *Packer) PackUint64(v uint64) *Packer   { return p.packScalar('Q', v) }
+func (p *Packer) PackFloat32(v float32) *Packer { return p.packScalar('f', v) }
+func (p *Packer) PackFloat64(v float64) *Packer { return p.packScalar('d', v) }
+
+// PackUvarint appends v as an unsigned LEB128 varint.
+func (p *Packer) PackUvarint(v uint64) *Packer { return p.packScalar('v', v) }
+
+// PackVarint appends v as a signed zigzag varint.
+func (p *Packer) PackVarint(v int64) *Packer { return p.packScalar('z', v) }
+
+// PackBytes appends b as-is, with no length prefix; pair it with a
+// preceding PackUint32(uint32(len(b))) (or similar) so the reader knows how
+// many bytes to read back.
+func (p *Packer) PackBytes(b []byte) *Packer {
+	if p.err != nil {
+		return p
+	}
+	p.buf = append(p.buf, b...)
+	return p
+}
+
+// PackString appends v as a fixed-size field occupying exactly n bytes,
+// zero-padded if shorter, matching the "Ns" format token.
+func (p *Packer) PackString(v string, n int) *Packer {
+	if p.err != nil {
+		return p
+	}
+	if len(v) > n {
+		p.err = fmt.Errorf("string %q longer than declared size %d", v, n)
+		return p
+	}
+	p.buf = append(p.buf, v...)
+	for pad := n - len(v); pad > 0; pad-- {
+		p.buf = append(p.buf, 0)
+	}
+	return p
+}
+
+// Unpacker reads typed values off a byte slice using a cursor.
+type Unpacker struct {
+	data []byte
+	err  error
+}
+
+// NewUnpacker returns an Unpacker reading from data.
+func NewUnpacker(data []byte) *Unpacker {
+	return &Unpacker{data: data}
+}
+
+// Remaining returns the number of unread bytes.
+func (u *Unpacker) Remaining() int {
+	return len(u.data)
+}
+
+// Err returns the first error encountered by any Unpack*/Bytes call, or nil
+// if none occurred.
+func (u *Unpacker) Err() error {
+	return u.err
+}
+
+func (u *Unpacker) unpackScalar(letter byte) (interface{}, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+	value, n, err := unpackScalar(binary.BigEndian, letter, u.data)
+	if err != nil {
+		u.err = err
+		return nil, err
+	}
+	u.data = u.data[n:]
+	return value, nil
+}
+
+func (u *Unpacker) UnpackBool() (bool, error) {
+	v, err := u.unpackScalar('?')
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+func (u *Unpacker) UnpackByte() (byte, error) {
+	v, err := u.unpackScalar('B')
+	if err != nil {
+		return 0, err
+	}
+	return v.(byte), nil
+}
+
+func (u *Unpacker) UnpackInt16() (int16, error) {
+	v, err := u.unpackScalar('h')
+	if err != nil {
+		return 0, err
+	}
+	return v.(int16), nil
+}
+
+func (u *Unpacker) UnpackUint16() (uint16, error) {
+	v, err := u.unpackScalar('H')
+	if err != nil {
+		return 0, err
+	}
+	return v.(uint16), nil
+}
+
+func (u *Unpacker) UnpackInt32() (int32, error) {
+	v, err := u.unpackScalar('i')
+	if err != nil {
+		return 0, err
+	}
+	return v.(int32), nil
+}
+
+func (u *Unpacker) UnpackUint32() (uint32, error) {
+	v, err := u.unpackScalar('I')
+	if err != nil {
+		return 0, err
+	}
+	return v.(uint32), nil
+}
+
+func (u *Unpacker) UnpackInt64() (int64, error) {
+	v, err := u.unpackScalar('q')
+	if err != nil {
+		return 0, err
+	}
+	return v.(int64), nil
+}
+
+func (u *Unpacker) UnpackUint64() (uint64, error) {
+	v, err := u.unpackScalar('Q')
+	if err != nil {
+		return 0, err
+	}
+	return v.(uint64), nil
+}
+
+func (u *Unpacker) UnpackFloat32() (float32, error) {
+	v, err := u.unpackScalar('f')
+	if err != nil {
+		return 0, err
+	}
+	return v.(float32), nil
+}
+
+func (u *Unpacker) UnpackFloat64() (float64, error) {
+	v, err := u.unpackScalar('d')
+	if err != nil {
+		return 0, err
+	}
+	return v.(float64), nil
+}
+
+// UnpackUvarint reads an unsigned LEB128 varint.
+func (u *Unpacker) UnpackUvarint() (uint64, error) {
+	v, err := u.unpackScalar('v')
+	if err != nil {
+		return 0, err
+	}
+	return v.(uint64), nil
+}
+
+// UnpackVarint reads a signed zigzag varint.
+func (u *Unpacker) UnpackVarint() (int64, error) {
+	v, err := u.unpackScalar('z')
+	if err != nil {
+		return 0, err
+	}
+	return v.(int64), nil
+}
+
+// Bytes reads and returns the next n bytes as-is.
+func (u *Unpacker) Bytes(n int) ([]byte, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+	if len(u.data) < n {
+		u.err = errShortMessage
+		return nil, u.err
+	}
+	b := u.data[:n]
+	u.data = u.data[n:]
+	return b, nil
+}
+
+// UnpackString reads a fixed-size field of n bytes and returns it verbatim
+// as a string, matching the "Ns" format token - including any trailing pad
+// bytes a PackString call wrote.
+func (u *Unpacker) UnpackString(n int) (string, error) {
+	b, err := u.Bytes(n)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
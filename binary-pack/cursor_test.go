@@ -0,0 +1,83 @@
+package binary_pack
+
+import "testing"
+
+func TestPackerUnpackerRoundTrip(t *testing.T) {
+	p := NewPacker()
+	p.PackBool(true).
+		PackByte(0x7F).
+		PackInt16(-1).
+		PackUint32(0xDEADBEEF).
+		PackUvarint(300).
+		PackVarint(-150).
+		PackString("hi", 4).
+		PackBytes([]byte{1, 2, 3})
+	if err := p.Err(); err != nil {
+		t.Fatalf("Packer: %v", err)
+	}
+
+	u := NewUnpacker(p.Bytes())
+
+	if v, err := u.UnpackBool(); err != nil || v != true {
+		t.Fatalf("UnpackBool: got (%v, %v), want (true, nil)", v, err)
+	}
+	if v, err := u.UnpackByte(); err != nil || v != 0x7F {
+		t.Fatalf("UnpackByte: got (%v, %v), want (0x7F, nil)", v, err)
+	}
+	if v, err := u.UnpackInt16(); err != nil || v != -1 {
+		t.Fatalf("UnpackInt16: got (%v, %v), want (-1, nil)", v, err)
+	}
+	if v, err := u.UnpackUint32(); err != nil || v != 0xDEADBEEF {
+		t.Fatalf("UnpackUint32: got (%v, %v), want (0xDEADBEEF, nil)", v, err)
+	}
+	if v, err := u.UnpackUvarint(); err != nil || v != 300 {
+		t.Fatalf("UnpackUvarint: got (%v, %v), want (300, nil)", v, err)
+	}
+	if v, err := u.UnpackVarint(); err != nil || v != -150 {
+		t.Fatalf("UnpackVarint: got (%v, %v), want (-150, nil)", v, err)
+	}
+	if v, err := u.UnpackString(4); err != nil || v != "hi\x00\x00" {
+		t.Fatalf("UnpackString: got (%q, %v), want (\"hi\\x00\\x00\", nil)", v, err)
+	}
+	b, err := u.Bytes(3)
+	if err != nil || string(b) != "\x01\x02\x03" {
+		t.Fatalf("Bytes: got (%v, %v), want ([1 2 3], nil)", b, err)
+	}
+	if u.Remaining() != 0 {
+		t.Fatalf("Remaining: got %d, want 0", u.Remaining())
+	}
+	if err := u.Err(); err != nil {
+		t.Fatalf("Unpacker: %v", err)
+	}
+}
+
+func TestPackerStickyError(t *testing.T) {
+	p := NewPacker()
+	p.PackString("hello world", 3) // too long: latches an error
+	if p.Err() == nil {
+		t.Fatal("expected PackString to set a sticky error for an oversized string")
+	}
+
+	before := len(p.Bytes())
+	p.PackByte(0xFF) // must be a no-op once err is set
+	if len(p.Bytes()) != before {
+		t.Fatalf("PackByte wrote to the buffer after a prior error; buffer grew from %d to %d bytes", before, len(p.Bytes()))
+	}
+	if p.Err() == nil {
+		t.Fatal("expected Err to still report the first error after a later call")
+	}
+}
+
+func TestUnpackerStickyError(t *testing.T) {
+	u := NewUnpacker([]byte{0x01}) // one byte: enough for a bool, not for the uint32 that follows
+	if _, err := u.UnpackBool(); err != nil {
+		t.Fatalf("UnpackBool: %v", err)
+	}
+	if _, err := u.UnpackUint32(); err == nil {
+		t.Fatal("expected UnpackUint32 to fail on a short buffer")
+	}
+
+	if _, err := u.UnpackByte(); err == nil {
+		t.Fatal("expected a later call to keep returning the sticky error")
+	}
+}
@@ -0,0 +1,587 @@
+package binary_pack
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+/*
+	This file holds the format-token engine shared by Pack/UnPack, AppendPack/
+	PackTo/UnPackFrom and Marshal/Unmarshal: parsing a token like "<4h" or
+	"@i" once, and packing/unpacking a single scalar value or a fixed-size
+	slice of them. Consolidating this here is what let the byte-order prefix,
+	the repeat-count and the signed/unsigned split land in one place instead
+	of five.
+
+	Format letters:
+		? - bool, packed size 1 byte
+		B - uint8, packed size 1 byte
+		c - byte, packed size 1 byte (kept distinct from B for parity with Python's struct)
+		h, H - int16, uint16, packed size 2 bytes
+		i, I, l, L - int32, uint32, packed size 4 bytes
+		q, Q - int64, uint64, packed size 8 bytes
+		f - float32, packed size 4 bytes
+		d - float64, packed size 8 bytes
+		v - uint64, unsigned LEB128 varint, packed size 1-10 bytes
+		z - int64, zigzag varint, packed size 1-10 bytes
+		Ns - string, packed size N bytes
+		Np - Pascal-style length-prefixed string, packed size N bytes (1 length byte + N-1 data bytes)
+		Nx - N pad bytes; doesn't consume or produce a value
+
+	A leading count before any other letter is a repeat count: the field then
+	packs/unpacks a slice of that many values instead of a single one (e.g.
+	"4h" is a []int16 of length 4). A byte-order prefix of "<" or ">" selects
+	little/big endian as before; "@" or "=" selects binary.NativeEndian. A
+	token with no prefix inherits whatever byte order was last selected,
+	defaulting to big endian.
+*/
+
+// parseToken splits one format token into its byte order, format letter and
+// leading count (1 if none is given). endianess carries the byte order
+// selected by a previous token so that one without its own prefix inherits
+// it.
+func parseToken(f string, endianess binary.ByteOrder) (binary.ByteOrder, byte, int, error) {
+	if f == "" {
+		return endianess, 0, 0, errors.New("empty format token")
+	}
+
+	switch f[0] {
+	case '<':
+		endianess = binary.LittleEndian
+		f = f[1:]
+	case '>':
+		endianess = binary.BigEndian
+		f = f[1:]
+	case '@', '=':
+		endianess = binary.NativeEndian
+		f = f[1:]
+	}
+
+	if f == "" {
+		return endianess, 0, 0, errors.New("empty format token")
+	}
+
+	digits := 0
+	for digits < len(f)-1 && f[digits] >= '0' && f[digits] <= '9' {
+		digits++
+	}
+
+	count := 1
+	if digits > 0 {
+		n, err := strconv.Atoi(f[:digits])
+		if err != nil {
+			return endianess, 0, 0, fmt.Errorf("invalid format token %q", f)
+		}
+		count = n
+	}
+
+	letter := f[digits]
+	if len(f) != digits+1 {
+		return endianess, 0, 0, fmt.Errorf("invalid format token %q", f)
+	}
+
+	switch letter {
+	case '?', 'B', 'c', 'h', 'H', 'i', 'I', 'l', 'L', 'q', 'Q', 'f', 'd', 'v', 'z', 's', 'x', 'p':
+		return endianess, letter, count, nil
+	}
+
+	return endianess, 0, 0, fmt.Errorf("unexpected format token: '%s'", f)
+}
+
+// isSized reports whether letter's leading count is a byte count ("s"/"x"/"p")
+// rather than a repeat count.
+func isSized(letter byte) bool {
+	return letter == 's' || letter == 'x' || letter == 'p'
+}
+
+// scalarSize returns the packed size in bytes of a single value of the given
+// scalar letter, or 0 for the variable-length "v"/"z" varint letters.
+func scalarSize(letter byte) int {
+	switch letter {
+	case '?', 'B', 'c':
+		return 1
+	case 'h', 'H':
+		return 2
+	case 'i', 'I', 'l', 'L', 'f':
+		return 4
+	case 'q', 'Q', 'd':
+		return 8
+	}
+	return 0
+}
+
+// elemTypeFor returns the Go type a single value of the given scalar letter
+// decodes to, or nil if letter isn't a scalar letter.
+func elemTypeFor(letter byte) reflect.Type {
+	switch letter {
+	case '?':
+		return reflect.TypeOf(bool(false))
+	case 'B', 'c':
+		return reflect.TypeOf(uint8(0))
+	case 'h':
+		return reflect.TypeOf(int16(0))
+	case 'H':
+		return reflect.TypeOf(uint16(0))
+	case 'i', 'l':
+		return reflect.TypeOf(int32(0))
+	case 'I', 'L':
+		return reflect.TypeOf(uint32(0))
+	case 'q':
+		return reflect.TypeOf(int64(0))
+	case 'Q':
+		return reflect.TypeOf(uint64(0))
+	case 'f':
+		return reflect.TypeOf(float32(0))
+	case 'd':
+		return reflect.TypeOf(float64(0))
+	case 'v':
+		return reflect.TypeOf(uint64(0))
+	case 'z':
+		return reflect.TypeOf(int64(0))
+	}
+	return nil
+}
+
+var errShortMessage = errors.New("Expected size is bigger than actual size of message")
+
+// packScalar appends a single value of the given scalar letter to dst.
+func packScalar(dst []byte, appender binary.AppendByteOrder, letter byte, value interface{}) ([]byte, error) {
+	switch letter {
+	case '?':
+		v, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected bool, got %T", value)
+		}
+		if v {
+			return append(dst, 1), nil
+		}
+		return append(dst, 0), nil
+	case 'B', 'c':
+		v, ok := value.(uint8)
+		if !ok {
+			return nil, fmt.Errorf("expected uint8, got %T", value)
+		}
+		return append(dst, v), nil
+	case 'h':
+		v, ok := value.(int16)
+		if !ok {
+			return nil, fmt.Errorf("expected int16, got %T", value)
+		}
+		return appender.AppendUint16(dst, uint16(v)), nil
+	case 'H':
+		v, ok := value.(uint16)
+		if !ok {
+			return nil, fmt.Errorf("expected uint16, got %T", value)
+		}
+		return appender.AppendUint16(dst, v), nil
+	case 'i', 'l':
+		v, ok := value.(int32)
+		if !ok {
+			return nil, fmt.Errorf("expected int32, got %T", value)
+		}
+		return appender.AppendUint32(dst, uint32(v)), nil
+	case 'I', 'L':
+		v, ok := value.(uint32)
+		if !ok {
+			return nil, fmt.Errorf("expected uint32, got %T", value)
+		}
+		return appender.AppendUint32(dst, v), nil
+	case 'q':
+		v, ok := value.(int64)
+		if !ok {
+			return nil, fmt.Errorf("expected int64, got %T", value)
+		}
+		return appender.AppendUint64(dst, uint64(v)), nil
+	case 'Q':
+		v, ok := value.(uint64)
+		if !ok {
+			return nil, fmt.Errorf("expected uint64, got %T", value)
+		}
+		return appender.AppendUint64(dst, v), nil
+	case 'f':
+		v, ok := value.(float32)
+		if !ok {
+			return nil, fmt.Errorf("expected float32, got %T", value)
+		}
+		return appender.AppendUint32(dst, math.Float32bits(v)), nil
+	case 'd':
+		v, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("expected float64, got %T", value)
+		}
+		return appender.AppendUint64(dst, math.Float64bits(v)), nil
+	case 'v':
+		v, ok := value.(uint64)
+		if !ok {
+			return nil, fmt.Errorf("expected uint64, got %T", value)
+		}
+		var buf [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(buf[:], v)
+		return append(dst, buf[:n]...), nil
+	case 'z':
+		v, ok := value.(int64)
+		if !ok {
+			return nil, fmt.Errorf("expected int64, got %T", value)
+		}
+		var buf [binary.MaxVarintLen64]byte
+		n := binary.PutVarint(buf[:], v)
+		return append(dst, buf[:n]...), nil
+	}
+	return nil, fmt.Errorf("unexpected format letter '%c'", letter)
+}
+
+// packField appends count values of the given scalar letter to dst. If
+// count is 1, value must be a single scalar of the matching Go type;
+// otherwise it must be a slice of exactly count of them.
+func packField(dst []byte, endianess binary.ByteOrder, letter byte, count int, value interface{}) ([]byte, error) {
+	appender := endianess.(binary.AppendByteOrder)
+
+	if count == 1 {
+		return packScalar(dst, appender, letter, value)
+	}
+
+	rv := reflect.ValueOf(value)
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Len() != count {
+		return nil, fmt.Errorf("expected a slice or array of %d values for format letter '%c'", count, letter)
+	}
+	for j := 0; j < count; j++ {
+		var err error
+		dst, err = packScalar(dst, appender, letter, rv.Index(j).Interface())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// unpackScalar decodes a single value of the given scalar letter from the
+// front of b, returning the value and the number of bytes it consumed.
+func unpackScalar(endianess binary.ByteOrder, letter byte, b []byte) (interface{}, int, error) {
+	switch letter {
+	case '?':
+		if len(b) < 1 {
+			return nil, 0, errShortMessage
+		}
+		return b[0] != 0, 1, nil
+	case 'B', 'c':
+		if len(b) < 1 {
+			return nil, 0, errShortMessage
+		}
+		return b[0], 1, nil
+	case 'h':
+		if len(b) < 2 {
+			return nil, 0, errShortMessage
+		}
+		return int16(endianess.Uint16(b)), 2, nil
+	case 'H':
+		if len(b) < 2 {
+			return nil, 0, errShortMessage
+		}
+		return endianess.Uint16(b), 2, nil
+	case 'i', 'l':
+		if len(b) < 4 {
+			return nil, 0, errShortMessage
+		}
+		return int32(endianess.Uint32(b)), 4, nil
+	case 'I', 'L':
+		if len(b) < 4 {
+			return nil, 0, errShortMessage
+		}
+		return endianess.Uint32(b), 4, nil
+	case 'q':
+		if len(b) < 8 {
+			return nil, 0, errShortMessage
+		}
+		return int64(endianess.Uint64(b)), 8, nil
+	case 'Q':
+		if len(b) < 8 {
+			return nil, 0, errShortMessage
+		}
+		return endianess.Uint64(b), 8, nil
+	case 'f':
+		if len(b) < 4 {
+			return nil, 0, errShortMessage
+		}
+		return math.Float32frombits(endianess.Uint32(b)), 4, nil
+	case 'd':
+		if len(b) < 8 {
+			return nil, 0, errShortMessage
+		}
+		return math.Float64frombits(endianess.Uint64(b)), 8, nil
+	case 'v':
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, 0, errors.New("Invalid varint for format token 'v'")
+		}
+		return v, n, nil
+	case 'z':
+		v, n := binary.Varint(b)
+		if n <= 0 {
+			return nil, 0, errors.New("Invalid varint for format token 'z'")
+		}
+		return v, n, nil
+	}
+	return nil, 0, fmt.Errorf("unexpected format letter '%c'", letter)
+}
+
+// unpackField decodes count values of the given scalar letter from the front
+// of b, returning a single value (count == 1) or a slice of them, plus the
+// number of bytes consumed.
+func unpackField(endianess binary.ByteOrder, letter byte, count int, b []byte) (interface{}, int, error) {
+	if count == 1 {
+		return unpackScalar(endianess, letter, b)
+	}
+
+	elemType := elemTypeFor(letter)
+	if elemType == nil {
+		return nil, 0, fmt.Errorf("unexpected format letter '%c'", letter)
+	}
+
+	slice := reflect.MakeSlice(reflect.SliceOf(elemType), count, count)
+	consumed := 0
+	for j := 0; j < count; j++ {
+		v, n, err := unpackScalar(endianess, letter, b[consumed:])
+		if err != nil {
+			return nil, 0, err
+		}
+		slice.Index(j).Set(reflect.ValueOf(v))
+		consumed += n
+	}
+	return slice.Interface(), consumed, nil
+}
+
+// packFieldTo writes count values of the given scalar letter to w, using
+// scratch as a reusable encode buffer so that PackTo never materializes the
+// whole field in memory. It returns the number of bytes written.
+func packFieldTo(w io.Writer, scratch []byte, endianess binary.ByteOrder, letter byte, count int, value interface{}) (int, error) {
+	appender := endianess.(binary.AppendByteOrder)
+	written := 0
+
+	writeOne := func(elem interface{}) error {
+		buf, err := packScalar(scratch[:0], appender, letter, elem)
+		if err != nil {
+			return err
+		}
+		n, err := w.Write(buf)
+		written += n
+		return err
+	}
+
+	if count == 1 {
+		return written, writeOne(value)
+	}
+
+	rv := reflect.ValueOf(value)
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Len() != count {
+		return written, fmt.Errorf("expected a slice or array of %d values for format letter '%c'", count, letter)
+	}
+	for j := 0; j < count; j++ {
+		if err := writeOne(rv.Index(j).Interface()); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// unpackScalarFrom reads a single value of the given scalar letter from r,
+// using br for the "v"/"z" varint letters (which decode one byte at a time
+// via io.ByteReader) and scratch as a reusable decode buffer otherwise.
+func unpackScalarFrom(r io.Reader, br io.ByteReader, scratch []byte, endianess binary.ByteOrder, letter byte) (interface{}, error) {
+	if letter == 'v' {
+		return binary.ReadUvarint(br)
+	}
+	if letter == 'z' {
+		return binary.ReadVarint(br)
+	}
+
+	size := scalarSize(letter)
+	if size == 0 {
+		return nil, fmt.Errorf("unexpected format letter '%c'", letter)
+	}
+	if _, err := io.ReadFull(r, scratch[:size]); err != nil {
+		return nil, err
+	}
+	value, _, err := unpackScalar(endianess, letter, scratch[:size])
+	return value, err
+}
+
+// unpackFieldFrom reads count values of the given scalar letter from r,
+// returning a single value (count == 1) or a slice of them.
+func unpackFieldFrom(r io.Reader, br io.ByteReader, scratch []byte, endianess binary.ByteOrder, letter byte, count int) (interface{}, error) {
+	if count == 1 {
+		return unpackScalarFrom(r, br, scratch, endianess, letter)
+	}
+
+	elemType := elemTypeFor(letter)
+	if elemType == nil {
+		return nil, fmt.Errorf("unexpected format letter '%c'", letter)
+	}
+
+	slice := reflect.MakeSlice(reflect.SliceOf(elemType), count, count)
+	for j := 0; j < count; j++ {
+		v, err := unpackScalarFrom(r, br, scratch, endianess, letter)
+		if err != nil {
+			return nil, err
+		}
+		slice.Index(j).Set(reflect.ValueOf(v))
+	}
+	return slice.Interface(), nil
+}
+
+// nextToken parses one format token via parseToken, threading the running
+// byte order through *endianess and returning just the letter and count -
+// the shape every Pack/UnPack-family loop needs.
+func nextToken(f string, endianess *binary.ByteOrder) (byte, int, error) {
+	order, letter, count, err := parseToken(f, *endianess)
+	if err != nil {
+		return 0, 0, err
+	}
+	*endianess = order
+	return letter, count, nil
+}
+
+// formatOp is one fully-resolved format token: its scalar letter, its count
+// (a repeat count, or a byte count for "s"/"x"/"p") and the byte order in
+// effect for it. compileFormat turns a []string format into a []formatOp
+// once so that a *Format can replay it without re-parsing the byte-order
+// prefix, repeat count and scalar letter on every Pack/UnPack call.
+type formatOp struct {
+	letter    byte
+	count     int
+	endianess binary.ByteOrder
+}
+
+// compileFormat parses format into a []formatOp, resolving the byte order
+// each token inherits or selects along the way.
+func compileFormat(format []string) ([]formatOp, error) {
+	ops := make([]formatOp, 0, len(format))
+	var endianess binary.ByteOrder = binary.BigEndian
+
+	for _, f := range format {
+		letter, count, err := nextToken(f, &endianess)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, formatOp{letter: letter, count: count, endianess: endianess})
+	}
+
+	return ops, nil
+}
+
+// opsSize sums the packed size of ops. varintSize is the size attributed to
+// each "v"/"z" op; 0 means such an op is an error (CalcSize, Compile), any
+// other value bounds it (CalcMinSize/CalcMaxSize).
+func opsSize(ops []formatOp, varintSize int) (int, error) {
+	var size int
+
+	for _, op := range ops {
+		switch op.letter {
+		case 'v', 'z':
+			if varintSize == 0 {
+				return 0, fmt.Errorf("Format contains variable-length token '%c': use CalcMinSize/CalcMaxSize instead of CalcSize", op.letter)
+			}
+			size += op.count * varintSize
+		case 's', 'x', 'p':
+			size += op.count
+		default:
+			size += op.count * scalarSize(op.letter)
+		}
+	}
+
+	return size, nil
+}
+
+// unpackOps decodes msg according to ops, the compiled form of a Pack/UnPack
+// format. It is the engine behind both UnPack (which compiles format fresh
+// on every call) and Format.UnPack (which replays an already-compiled one).
+func unpackOps(ops []formatOp, msg []byte) ([]interface{}, error) {
+	res := make([]interface{}, 0, len(ops))
+
+	for _, op := range ops {
+		switch op.letter {
+		case 'x':
+			if len(msg) < op.count {
+				return nil, errShortMessage
+			}
+			msg = msg[op.count:]
+		case 's':
+			if len(msg) < op.count {
+				return nil, errShortMessage
+			}
+			res = append(res, string(msg[:op.count]))
+			msg = msg[op.count:]
+		case 'p':
+			value, n, err := unpackPascalString(msg, op.count)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, value)
+			msg = msg[n:]
+		default:
+			value, n, err := unpackField(op.endianess, op.letter, op.count, msg)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, value)
+			msg = msg[n:]
+		}
+	}
+
+	return res, nil
+}
+
+// appendPackOps appends msg, packed according to ops, to dst. It is the
+// engine behind both AppendPack (which compiles format fresh on every call)
+// and Format.Pack (which replays an already-compiled one).
+func appendPackOps(dst []byte, ops []formatOp, msg []interface{}) ([]byte, error) {
+	mi := 0
+
+	for _, op := range ops {
+		if op.letter == 'x' {
+			dst = append(dst, make([]byte, op.count)...)
+			continue
+		}
+
+		if mi >= len(msg) {
+			return nil, fmt.Errorf("not enough values to pack: format needs a value for a '%c' token but only %d were given", op.letter, len(msg))
+		}
+
+		var err error
+		switch op.letter {
+		case 's':
+			casted_value, ok := msg[mi].(string)
+			if !ok {
+				return nil, fmt.Errorf("Type of passed value doesn't match to expected '%ds' (string)", op.count)
+			}
+			if len(casted_value) > op.count {
+				return nil, fmt.Errorf("string %q longer than declared size %d", casted_value, op.count)
+			}
+			dst = append(dst, casted_value...)
+			for pad := op.count - len(casted_value); pad > 0; pad-- {
+				dst = append(dst, 0)
+			}
+		case 'p':
+			casted_value, ok := msg[mi].(string)
+			if !ok {
+				return nil, fmt.Errorf("Type of passed value doesn't match to expected '%dp' (string)", op.count)
+			}
+			dst, err = packPascalString(dst, op.count, casted_value)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			dst, err = packField(dst, op.endianess, op.letter, op.count, msg[mi])
+			if err != nil {
+				return nil, fmt.Errorf("value for a '%c' token: %s", op.letter, err)
+			}
+		}
+		mi++
+	}
+
+	return dst, nil
+}
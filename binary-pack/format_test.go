@@ -0,0 +1,142 @@
+package binary_pack
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestVarintRoundTrip(t *testing.T) {
+	bp := &BinaryPack{}
+	format := []string{"v", "z"}
+	msg := []interface{}{uint64(300), int64(-150)}
+
+	data, err := bp.Pack(format, msg)
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	maxSize, err := bp.CalcMaxSize(format)
+	if err != nil {
+		t.Fatalf("CalcMaxSize: %v", err)
+	}
+	if len(data) >= maxSize {
+		t.Fatalf("packed varints should be shorter than the max bound for small values, got %d bytes", len(data))
+	}
+
+	out, err := bp.UnPack(format, data)
+	if err != nil {
+		t.Fatalf("UnPack: %v", err)
+	}
+	if !reflect.DeepEqual(out, msg) {
+		t.Fatalf("varint round trip mismatch: got %v, want %v", out, msg)
+	}
+}
+
+func TestSignedUnsignedIntRoundTrip(t *testing.T) {
+	bp := &BinaryPack{}
+	format := []string{"h", "H", "i", "I", "q", "Q"}
+	msg := []interface{}{int16(-1), uint16(0xFFFF), int32(-1), uint32(0xFFFFFFFF), int64(-1), uint64(0xFFFFFFFFFFFFFFFF)}
+
+	data, err := bp.Pack(format, msg)
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+
+	out, err := bp.UnPack(format, data)
+	if err != nil {
+		t.Fatalf("UnPack: %v", err)
+	}
+	if !reflect.DeepEqual(out, msg) {
+		t.Fatalf("signed/unsigned round trip mismatch: got %v, want %v", out, msg)
+	}
+}
+
+func TestCToken(t *testing.T) {
+	bp := &BinaryPack{}
+	format := []string{"c"}
+	msg := []interface{}{uint8(0x41)}
+
+	data, err := bp.Pack(format, msg)
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	if !reflect.DeepEqual(data, []byte{0x41}) {
+		t.Fatalf("'c' should pack like 'B', got %v", data)
+	}
+
+	out, err := bp.UnPack(format, data)
+	if err != nil {
+		t.Fatalf("UnPack: %v", err)
+	}
+	if !reflect.DeepEqual(out, msg) {
+		t.Fatalf("'c' round trip mismatch: got %v, want %v", out, msg)
+	}
+}
+
+func TestPadBytesToken(t *testing.T) {
+	bp := &BinaryPack{}
+	format := []string{"H", "3x", "H"}
+	msg := []interface{}{uint16(1), uint16(2)} // "x" doesn't consume a msg value
+
+	size, err := bp.CalcSize(format)
+	if err != nil {
+		t.Fatalf("CalcSize: %v", err)
+	}
+	if size != 2+3+2 {
+		t.Fatalf("CalcSize: got %d, want %d", size, 2+3+2)
+	}
+
+	data, err := bp.Pack(format, msg)
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+	want := []byte{0, 1, 0, 0, 0, 0, 2}
+	if !reflect.DeepEqual(data, want) {
+		t.Fatalf("Pack: got %v, want %v", data, want)
+	}
+
+	out, err := bp.UnPack(format, data)
+	if err != nil {
+		t.Fatalf("UnPack: %v", err)
+	}
+	if !reflect.DeepEqual(out, msg) {
+		t.Fatalf("'x' round trip mismatch: got %v, want %v (pad bytes should produce no value)", out, msg)
+	}
+}
+
+func TestNativeEndianToken(t *testing.T) {
+	bp := &BinaryPack{}
+	msg := []interface{}{uint32(0x01020304)}
+
+	gotAt, err := bp.Pack([]string{"@I"}, msg)
+	if err != nil {
+		t.Fatalf("Pack with '@': %v", err)
+	}
+	want := binary.NativeEndian.AppendUint32(nil, 0x01020304)
+	if !reflect.DeepEqual(gotAt, want) {
+		t.Fatalf("'@I' should use native byte order: got %v, want %v", gotAt, want)
+	}
+
+	gotEq, err := bp.Pack([]string{"=I"}, msg)
+	if err != nil {
+		t.Fatalf("Pack with '=': %v", err)
+	}
+	if !reflect.DeepEqual(gotEq, want) {
+		t.Fatalf("'=I' should use native byte order: got %v, want %v", gotEq, want)
+	}
+
+	out, err := bp.UnPack([]string{"@I"}, gotAt)
+	if err != nil {
+		t.Fatalf("UnPack with '@': %v", err)
+	}
+	if !reflect.DeepEqual(out, msg) {
+		t.Fatalf("'@I' round trip mismatch: got %v, want %v", out, msg)
+	}
+}
+
+func TestCalcSizeRejectsVarintTokens(t *testing.T) {
+	bp := &BinaryPack{}
+	if _, err := bp.CalcSize([]string{"v"}); err == nil {
+		t.Fatal("expected CalcSize to reject a 'v' token, got nil error")
+	}
+}
@@ -0,0 +1,241 @@
+package binary_pack
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*
+	Marshal/Unmarshal work like Pack/UnPack but derive the format and the values to
+	pack straight from a struct via reflection, instead of requiring the caller to
+	hand-maintain a parallel []string format and []interface{} msg. Each field that
+	should be packed needs a `binpack` struct tag using the very same tokens Pack
+	understands (e.g. `binpack:"<i"`, `binpack:"20s"`, `binpack:">q"`, `binpack:"4h"`,
+	`binpack:"@I"`). Untagged struct fields are packed as nested structs; a tag of
+	"-" skips the field entirely. A leading digit on the "s" token fixes the
+	size of a string, byte array or byte slice field, and on the "p" token the
+	size of a string field ("p" accepts no other field type); on any other token it's a
+	repeat count, and the field must then be a fixed-size array or slice of that
+	many values. A leading digit on the "x" token declares that many pad bytes are
+	written on Marshal and simply skipped on Unmarshal.
+*/
+
+// Marshal packs the exported, binpack-tagged fields of the struct pointed to (or
+// held) by v into a byte slice.
+func (bp *BinaryPack) Marshal(v interface{}) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("binary_pack: Marshal expects a struct or pointer to struct, got %s", rv.Kind())
+	}
+
+	var res []byte
+	if err := marshalStruct(&res, rv); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Unmarshal unpacks data into the exported, binpack-tagged fields of the struct
+// pointed to by v.
+func (bp *BinaryPack) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("binary_pack: Unmarshal expects a pointer to struct, got %s", rv.Kind())
+	}
+
+	_, err := unmarshalStruct(data, rv.Elem())
+	return err
+}
+
+func marshalStruct(res *[]byte, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+
+		tag, hasTag := field.Tag.Lookup("binpack")
+		if hasTag && tag == "-" {
+			continue
+		}
+
+		fv := rv.Field(i)
+
+		if !hasTag && fv.Kind() == reflect.Struct {
+			if err := marshalStruct(res, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		endianess, letter, count, err := parseFieldTag(tag)
+		if err != nil {
+			return fmt.Errorf("binary_pack: field %q: %s", field.Name, err)
+		}
+
+		switch letter {
+		case 'x':
+			*res = append(*res, make([]byte, count)...)
+		case 's':
+			b, err := marshalFixedBytes(fv, count)
+			if err != nil {
+				return fmt.Errorf("binary_pack: field %q: %s", field.Name, err)
+			}
+			*res = append(*res, b...)
+		case 'p':
+			s, ok := fv.Interface().(string)
+			if !ok {
+				return fmt.Errorf("binary_pack: field %q: expected string for 'p', got %s", field.Name, fv.Kind())
+			}
+			*res, err = packPascalString(*res, count, s)
+			if err != nil {
+				return fmt.Errorf("binary_pack: field %q: %s", field.Name, err)
+			}
+		default:
+			*res, err = packField(*res, endianess, letter, count, fv.Interface())
+			if err != nil {
+				return fmt.Errorf("binary_pack: field %q: %s", field.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func unmarshalStruct(data []byte, rv reflect.Value) ([]byte, error) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+
+		tag, hasTag := field.Tag.Lookup("binpack")
+		if hasTag && tag == "-" {
+			continue
+		}
+
+		fv := rv.Field(i)
+
+		if !hasTag && fv.Kind() == reflect.Struct {
+			var err error
+			data, err = unmarshalStruct(data, fv)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		endianess, letter, count, err := parseFieldTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("binary_pack: field %q: %s", field.Name, err)
+		}
+
+		switch letter {
+		case 'x':
+			if len(data) < count {
+				return nil, errors.New("binary_pack: not enough data to unmarshal pad bytes")
+			}
+			data = data[count:]
+		case 's':
+			if len(data) < count {
+				return nil, fmt.Errorf("binary_pack: not enough data for field %q", field.Name)
+			}
+			if err := unmarshalFixedBytes(fv, data[:count]); err != nil {
+				return nil, fmt.Errorf("binary_pack: field %q: %s", field.Name, err)
+			}
+			data = data[count:]
+		case 'p':
+			if fv.Kind() != reflect.String {
+				return nil, fmt.Errorf("binary_pack: field %q: expected string for 'p', got %s", field.Name, fv.Kind())
+			}
+			value, n, err := unpackPascalString(data, count)
+			if err != nil {
+				return nil, fmt.Errorf("binary_pack: field %q: %s", field.Name, err)
+			}
+			fv.SetString(value)
+			data = data[n:]
+		default:
+			value, n, err := unpackField(endianess, letter, count, data)
+			if err != nil {
+				return nil, fmt.Errorf("binary_pack: field %q: %s", field.Name, err)
+			}
+			if err := setFieldValue(fv, value); err != nil {
+				return nil, fmt.Errorf("binary_pack: field %q: %s", field.Name, err)
+			}
+			data = data[n:]
+		}
+	}
+	return data, nil
+}
+
+// parseFieldTag parses a binpack struct tag via the same parseToken used by
+// Pack/UnPack, starting from big endian since each field's tag is independent
+// of the ones before it.
+func parseFieldTag(tag string) (binary.ByteOrder, byte, int, error) {
+	if tag == "" {
+		return nil, 0, 0, errors.New("missing binpack tag")
+	}
+	return parseToken(tag, binary.BigEndian)
+}
+
+// setFieldValue assigns value, as produced by unpackField, to fv. Array
+// fields need a copy from the slice unpackField returns for repeat counts;
+// everything else is a direct, type-checked assignment.
+func setFieldValue(fv reflect.Value, value interface{}) error {
+	rv := reflect.ValueOf(value)
+	if fv.Kind() == reflect.Array && rv.Kind() == reflect.Slice {
+		if fv.Len() != rv.Len() {
+			return fmt.Errorf("expected array of length %d, got %d", fv.Len(), rv.Len())
+		}
+		reflect.Copy(fv, rv)
+		return nil
+	}
+	if !rv.Type().AssignableTo(fv.Type()) {
+		return fmt.Errorf("expected %s field, got %s", rv.Type(), fv.Type())
+	}
+	fv.Set(rv)
+	return nil
+}
+
+func marshalFixedBytes(fv reflect.Value, n int) ([]byte, error) {
+	switch fv.Kind() {
+	case reflect.String:
+		s := fv.String()
+		if len(s) > n {
+			return nil, fmt.Errorf("string %q longer than declared size %d", s, n)
+		}
+		b := make([]byte, n)
+		copy(b, s)
+		return b, nil
+	case reflect.Array, reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.Uint8 {
+			return nil, fmt.Errorf("expected byte array or slice for size %d, got %s", n, fv.Type())
+		}
+		b := make([]byte, n)
+		reflect.Copy(reflect.ValueOf(b), fv)
+		return b, nil
+	}
+	return nil, fmt.Errorf("expected string or byte array for size %d, got %s", n, fv.Kind())
+}
+
+func unmarshalFixedBytes(fv reflect.Value, b []byte) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(strings.TrimRight(string(b), "\x00"))
+	case reflect.Array, reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("expected byte array or slice, got %s", fv.Type())
+		}
+		if fv.Kind() == reflect.Slice {
+			fv.Set(reflect.MakeSlice(fv.Type(), len(b), len(b)))
+		}
+		reflect.Copy(fv, reflect.ValueOf(b))
+	default:
+		return fmt.Errorf("expected string or byte array, got %s", fv.Kind())
+	}
+	return nil
+}
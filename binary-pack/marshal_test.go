@@ -0,0 +1,48 @@
+package binary_pack
+
+import (
+	"reflect"
+	"testing"
+)
+
+type marshalTestStruct struct {
+	Magic   uint16   `binpack:"H"`
+	Name    string   `binpack:"8s"`
+	Count   int32    `binpack:"<i"`
+	Flags   [3]uint8 `binpack:"3B"`
+	ignored string
+	Skipped string `binpack:"-"`
+}
+
+func TestMarshalUnmarshalRoundTrip(t *testing.T) {
+	bp := &BinaryPack{}
+	in := marshalTestStruct{
+		Magic:   0xBEEF,
+		Name:    "hello",
+		Count:   -42,
+		Flags:   [3]uint8{1, 2, 3},
+		Skipped: "not packed",
+	}
+
+	data, err := bp.Marshal(&in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var out marshalTestStruct
+	if err := bp.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	out.Skipped = in.Skipped // "-" field is never touched by either side
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("round trip mismatch: got %+v, want %+v", out, in)
+	}
+}
+
+func TestMarshalRejectsNonStruct(t *testing.T) {
+	bp := &BinaryPack{}
+	if _, err := bp.Marshal(42); err == nil {
+		t.Fatal("expected error marshaling a non-struct, got nil")
+	}
+}
@@ -0,0 +1,185 @@
+package binary_pack
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// AppendPack appends the values of msg, packed according to format, to dst and
+// returns the extended slice. It is the basis Pack is built on: every
+// fixed-width value is written straight into dst with a ByteOrder.AppendUintN
+// call, so packing a record of single-value fields costs one allocation for
+// the parsed format plus whatever growing dst needs, not one per field; a
+// repeat-count field such as "4h" can add one per element. Pad ("x") tokens don't
+// consume a value from msg, so format and msg can run at different paces.
+func (bp *BinaryPack) AppendPack(dst []byte, format []string, msg []interface{}) ([]byte, error) {
+	ops, err := compileFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	return appendPackOps(dst, ops, msg)
+}
+
+// PackTo writes the values of msg, packed according to format, directly to w.
+// Unlike Pack/AppendPack it never materializes the whole record in memory:
+// each fixed-width field is encoded into one small scratch buffer, reused
+// for every field, before being written out, which is the right shape for packing
+// large records straight onto a network connection or file.
+func (bp *BinaryPack) PackTo(w io.Writer, format []string, msg []interface{}) (int, error) {
+	var endianess binary.ByteOrder = binary.BigEndian // defaults to big endian
+	var scratch [binary.MaxVarintLen64]byte
+	written := 0
+	mi := 0
+
+	for _, f := range format {
+		letter, count, err := nextToken(f, &endianess)
+		if err != nil {
+			return written, err
+		}
+
+		if letter == 'x' {
+			for pad := count; pad > 0; {
+				chunk := pad
+				if chunk > len(scratch) {
+					chunk = len(scratch)
+				}
+				for j := 0; j < chunk; j++ {
+					scratch[j] = 0
+				}
+				n, err := w.Write(scratch[:chunk])
+				written += n
+				if err != nil {
+					return written, err
+				}
+				pad -= chunk
+			}
+			continue
+		}
+
+		if mi >= len(msg) {
+			return written, fmt.Errorf("not enough values to pack: format needs a value for token %q but only %d were given", f, len(msg))
+		}
+
+		switch letter {
+		case 's':
+			casted_value, ok := msg[mi].(string)
+			if !ok {
+				return written, fmt.Errorf("Type of passed value doesn't match to expected '%s' (string)", f)
+			}
+			if len(casted_value) > count {
+				return written, fmt.Errorf("string %q longer than declared size %d", casted_value, count)
+			}
+			n, err := io.WriteString(w, casted_value)
+			written += n
+			if err != nil {
+				return written, err
+			}
+			for pad := count - len(casted_value); pad > 0; {
+				chunk := pad
+				if chunk > len(scratch) {
+					chunk = len(scratch)
+				}
+				for j := 0; j < chunk; j++ {
+					scratch[j] = 0
+				}
+				n, err := w.Write(scratch[:chunk])
+				written += n
+				if err != nil {
+					return written, err
+				}
+				pad -= chunk
+			}
+		case 'p':
+			casted_value, ok := msg[mi].(string)
+			if !ok {
+				return written, fmt.Errorf("Type of passed value doesn't match to expected '%s' (string)", f)
+			}
+			buf, err := packPascalString(nil, count, casted_value)
+			if err != nil {
+				return written, err
+			}
+			n, err := w.Write(buf)
+			written += n
+			if err != nil {
+				return written, err
+			}
+		default:
+			n, err := packFieldTo(w, scratch[:], endianess, letter, count, msg[mi])
+			written += n
+			if err != nil {
+				return written, err
+			}
+		}
+		mi++
+	}
+
+	return written, nil
+}
+
+// UnPackFrom reads and decodes data from r according to format. Unlike
+// UnPack it doesn't require the caller to already have the whole record
+// buffered: each field is read straight off r into a small scratch buffer,
+// which makes it suitable for decoding directly off a network connection or
+// file. If format contains "v"/"z" tokens and r doesn't already implement
+// io.ByteReader, UnPackFrom wraps it in a bufio.Reader so the varint decoder
+// can read it one byte at a time.
+func (bp *BinaryPack) UnPackFrom(r io.Reader, format []string) ([]interface{}, error) {
+	res := make([]interface{}, 0, len(format))
+
+	var byteReader io.ByteReader
+	for _, f := range format {
+		if f != "" && (f[len(f)-1] == 'v' || f[len(f)-1] == 'z') {
+			if br, ok := r.(io.ByteReader); ok {
+				byteReader = br
+			} else {
+				buffered := bufio.NewReader(r)
+				r = buffered
+				byteReader = buffered
+			}
+			break
+		}
+	}
+
+	var endianess binary.ByteOrder = binary.BigEndian // default big endian
+	var scratch [8]byte
+
+	for _, f := range format {
+		letter, count, err := nextToken(f, &endianess)
+		if err != nil {
+			return nil, err
+		}
+
+		switch letter {
+		case 'x':
+			if _, err := io.CopyN(io.Discard, r, int64(count)); err != nil {
+				return nil, err
+			}
+		case 's':
+			buf := make([]byte, count)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return nil, err
+			}
+			res = append(res, string(buf))
+		case 'p':
+			buf := make([]byte, count)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return nil, err
+			}
+			value, _, err := unpackPascalString(buf, count)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, value)
+		default:
+			value, err := unpackFieldFrom(r, byteReader, scratch[:], endianess, letter, count)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, value)
+		}
+	}
+
+	return res, nil
+}
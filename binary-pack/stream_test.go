@@ -0,0 +1,86 @@
+package binary_pack
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestAppendPackMatchesPack(t *testing.T) {
+	bp := &BinaryPack{}
+	format := []string{"<i", "2x", "4p", "H"}
+	msg := []interface{}{int32(-7), "hi", uint16(42)}
+
+	packed, err := bp.Pack(format, msg)
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+
+	prefix := []byte{0xAA, 0xBB}
+	appended, err := bp.AppendPack(append([]byte(nil), prefix...), format, msg)
+	if err != nil {
+		t.Fatalf("AppendPack: %v", err)
+	}
+	if !bytes.Equal(appended[:len(prefix)], prefix) {
+		t.Fatalf("AppendPack didn't preserve dst's existing prefix: got %v", appended[:len(prefix)])
+	}
+	if !bytes.Equal(appended[len(prefix):], packed) {
+		t.Fatalf("AppendPack result diverges from Pack: got %x, want %x", appended[len(prefix):], packed)
+	}
+}
+
+func TestPackToMatchesPack(t *testing.T) {
+	bp := &BinaryPack{}
+	format := []string{"<i", "2x", "4p", "H"}
+	msg := []interface{}{int32(-7), "hi", uint16(42)}
+
+	packed, err := bp.Pack(format, msg)
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+
+	var buf bytes.Buffer
+	n, err := bp.PackTo(&buf, format, msg)
+	if err != nil {
+		t.Fatalf("PackTo: %v", err)
+	}
+	if n != len(packed) {
+		t.Fatalf("PackTo reported %d bytes written, Pack produced %d", n, len(packed))
+	}
+	if !bytes.Equal(buf.Bytes(), packed) {
+		t.Fatalf("PackTo result diverges from Pack: got %x, want %x", buf.Bytes(), packed)
+	}
+}
+
+func TestUnPackFromMatchesUnPack(t *testing.T) {
+	bp := &BinaryPack{}
+	format := []string{"<i", "2x", "4p", "H"}
+	msg := []interface{}{int32(-7), "hi", uint16(42)}
+
+	data, err := bp.Pack(format, msg)
+	if err != nil {
+		t.Fatalf("Pack: %v", err)
+	}
+
+	want, err := bp.UnPack(format, data)
+	if err != nil {
+		t.Fatalf("UnPack: %v", err)
+	}
+
+	got, err := bp.UnPackFrom(bytes.NewReader(data), format)
+	if err != nil {
+		t.Fatalf("UnPackFrom: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("UnPackFrom result diverges from UnPack: got %v, want %v", got, want)
+	}
+}
+
+func TestPackToRejectsOversizedString(t *testing.T) {
+	bp := &BinaryPack{}
+	var buf bytes.Buffer
+	_, err := bp.PackTo(&buf, []string{"3s"}, []interface{}{"hello world"})
+	if err == nil {
+		t.Fatal("expected PackTo to reject a string longer than its declared 's' size, got nil error")
+	}
+}